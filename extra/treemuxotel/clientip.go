@@ -0,0 +1,111 @@
+package treemuxotel
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithTrustedProxies sets the CIDR ranges that are trusted to set client IP
+// headers such as X-Forwarded-For. Requests whose socket peer
+// (req.RemoteAddr) does not fall inside one of these ranges are never
+// allowed to spoof their client IP via headers: the socket peer is used
+// as-is. Defaults to none, i.e. headers are never trusted.
+func WithTrustedProxies(cidrs []*net.IPNet) Option {
+	return func(c *config) {
+		c.trustedProxies = cidrs
+	}
+}
+
+// WithClientIPHeaders sets the ordered list of headers consulted for the
+// client IP once the request is known to come from a trusted proxy. The
+// first header with a usable value wins. Defaults to
+// []string{"X-Forwarded-For"}.
+func WithClientIPHeaders(headers []string) Option {
+	return func(c *config) {
+		c.clientIPHeaders = headers
+	}
+}
+
+// WithRemoteIPFunc overrides client IP resolution entirely. When set, none
+// of WithTrustedProxies, WithClientIPHeaders or the built-in
+// X-Forwarded-For parsing is consulted.
+func WithRemoteIPFunc(fn func(*http.Request) string) Option {
+	return func(c *config) {
+		c.remoteIPFunc = fn
+	}
+}
+
+func (c *config) remoteAddr(req *http.Request) string {
+	if c.remoteIPFunc != nil {
+		return c.remoteIPFunc(req)
+	}
+
+	peer, _, _ := net.SplitHostPort(req.RemoteAddr)
+	if peer == "" {
+		peer = req.RemoteAddr
+	}
+
+	if !c.isTrustedProxy(peer) {
+		return peer
+	}
+
+	for _, header := range c.clientIPHeaders {
+		value := req.Header.Get(header)
+		if value == "" {
+			continue
+		}
+
+		if strings.EqualFold(header, "X-Forwarded-For") {
+			if ip := c.clientIPFromForwardedFor(value); ip != "" {
+				return ip
+			}
+			continue
+		}
+
+		if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+			return ip.String()
+		}
+	}
+
+	return peer
+}
+
+// clientIPFromForwardedFor walks a comma-separated X-Forwarded-For list
+// right-to-left, the order in which proxies append their peer, skipping
+// entries that are themselves trusted proxies, and returns the first
+// address that is not.
+func (c *config) clientIPFromForwardedFor(header string) string {
+	parts := strings.Split(header, ",")
+	for i := len(parts) - 1; i >= 0; i-- {
+		candidate := strings.TrimSpace(parts[i])
+		if candidate == "" {
+			continue
+		}
+
+		ip := net.ParseIP(candidate)
+		if ip == nil {
+			continue
+		}
+		if c.isTrustedProxy(candidate) {
+			continue
+		}
+
+		return ip.String()
+	}
+	return ""
+}
+
+func (c *config) isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, trusted := range c.trustedProxies {
+		if trusted != nil && trusted.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package treemuxotel
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%q): %v", s, err)
+	}
+	return n
+}
+
+func TestConfigRemoteAddr(t *testing.T) {
+	tests := []struct {
+		name            string
+		trustedProxies  []string
+		clientIPHeaders []string
+		remoteAddr      string
+		headers         map[string]string
+		want            string
+	}{
+		{
+			name:       "no trusted proxies falls back to peer even with XFF present",
+			remoteAddr: "203.0.113.9:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:           "trusted proxy peer, single untrusted XFF entry",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			headers:        map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "walks XFF right-to-left, skipping trusted hops",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			headers:        map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2, 10.0.0.1"},
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "every XFF hop trusted falls back to peer",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			headers:        map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2, 10.0.0.1"},
+			want:           "10.0.0.1",
+		},
+		{
+			name:           "untrusted entry closest to the edge wins over a further spoofed one",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			headers:        map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.1, 10.0.0.1"},
+			want:           "198.51.100.1",
+		},
+		{
+			name:            "custom header list consults X-Real-Ip before X-Forwarded-For",
+			trustedProxies:  []string{"10.0.0.0/8"},
+			clientIPHeaders: []string{"X-Real-Ip", "X-Forwarded-For"},
+			remoteAddr:      "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Real-Ip":       "198.51.100.7",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.7",
+		},
+		{
+			name:           "malformed XFF entries are skipped",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			headers:        map[string]string{"X-Forwarded-For": "not-an-ip, 198.51.100.1"},
+			want:           "198.51.100.1",
+		},
+		{
+			name:       "RemoteAddr without a port is used as-is",
+			remoteAddr: "203.0.113.9",
+			want:       "203.0.113.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config{clientIPHeaders: []string{"X-Forwarded-For"}}
+			for _, cidr := range tt.trustedProxies {
+				c.trustedProxies = append(c.trustedProxies, mustCIDR(t, cidr))
+			}
+			if tt.clientIPHeaders != nil {
+				c.clientIPHeaders = tt.clientIPHeaders
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			if got := c.remoteAddr(req); got != tt.want {
+				t.Errorf("remoteAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigRemoteAddrWithRemoteIPFunc(t *testing.T) {
+	c := &config{
+		trustedProxies:  []*net.IPNet{mustCIDR(t, "10.0.0.0/8")},
+		clientIPHeaders: []string{"X-Forwarded-For"},
+		remoteIPFunc: func(*http.Request) string {
+			return "overridden"
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if got := c.remoteAddr(req); got != "overridden" {
+		t.Errorf("remoteAddr() = %q, want %q", got, "overridden")
+	}
+}
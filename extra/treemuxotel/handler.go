@@ -0,0 +1,118 @@
+package treemuxotel
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/vmihailenco/treemux"
+	"go.opentelemetry.io/otel/api/propagation"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// Middleware returns a treemux.MiddlewareFunc that starts a server span for
+// every request, extracting any propagated context from the incoming
+// headers first. Unlike NewMiddleware, it does not require a span to
+// already exist in the request context, so treemux does not need to be
+// wrapped in a separate otelhttp.NewHandler to get server spans.
+func Middleware(service string, opts ...Option) treemux.MiddlewareFunc {
+	c := newConfig(opts)
+	tracer := c.tracerProvider.Tracer(instrumentationName)
+	return func(next treemux.HandlerFunc) treemux.HandlerFunc {
+		return c.handle(tracer, service, next)
+	}
+}
+
+// Handler wraps next with the same span-creating behavior as Middleware.
+func Handler(next treemux.HandlerFunc, service string, opts ...Option) treemux.HandlerFunc {
+	c := newConfig(opts)
+	tracer := c.tracerProvider.Tracer(instrumentationName)
+	return c.handle(tracer, service, next)
+}
+
+func (c *config) handle(tracer trace.Tracer, service string, next treemux.HandlerFunc) treemux.HandlerFunc {
+	return func(w http.ResponseWriter, req treemux.Request) error {
+		if c.filter != nil && !c.filter(req) {
+			return next(w, req)
+		}
+
+		ctx := propagation.ExtractHTTP(req.Context(), c.propagators, req.Request.Header)
+
+		route := req.Route()
+		spanName := route
+		if c.spanNameFormatter != nil {
+			spanName = c.spanNameFormatter(route, &req)
+		}
+
+		startOpts := []trace.StartOption{
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(requestAttributes(service, req.Request)...),
+		}
+		if c.publicEndpointFn != nil && c.publicEndpointFn(req.Request) {
+			if remote := trace.RemoteSpanContextFromContext(ctx); remote.IsValid() {
+				startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: remote}))
+			}
+			ctx = trace.ContextWithRemoteSpanContext(ctx, trace.EmptySpanContext())
+		}
+		ctx, span := tracer.Start(ctx, spanName, startOpts...)
+		defer span.End()
+
+		req.Request = req.Request.WithContext(ctx)
+
+		span.SetAttributes(semconv.HTTPRouteKey.String(route))
+		if c.clientIP {
+			span.SetAttributes(semconv.HTTPClientIPKey.String(c.remoteAddr(req.Request)))
+		}
+		span.SetAttributes(c.paramAttributes(req)...)
+
+		rw := newResponseWriter(w)
+		err := next(rw, req)
+
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rw.status))
+		if rw.written > 0 {
+			span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int64(rw.written))
+		}
+
+		if status := spanStatusFromHTTPStatus(rw.status); status == codes.Error || err != nil {
+			span.SetStatus(codes.Error, statusDescription(rw.status, err))
+		}
+
+		return err
+	}
+}
+
+func requestAttributes(service string, req *http.Request) []label.KeyValue {
+	attrs := make([]label.KeyValue, 0, 8)
+	if service != "" {
+		attrs = append(attrs, semconv.HTTPServerNameKey.String(service))
+	}
+	attrs = append(attrs,
+		semconv.HTTPMethodKey.String(req.Method),
+		semconv.HTTPSchemeKey.String(scheme(req)),
+		semconv.HTTPHostKey.String(req.Host),
+		semconv.HTTPFlavorKey.String(strconv.Itoa(req.ProtoMajor)+"."+strconv.Itoa(req.ProtoMinor)),
+	)
+	if ua := req.UserAgent(); ua != "" {
+		attrs = append(attrs, semconv.HTTPUserAgentKey.String(ua))
+	}
+	if req.ContentLength > 0 {
+		attrs = append(attrs, semconv.HTTPRequestContentLengthKey.Int64(req.ContentLength))
+	}
+	return attrs
+}
+
+func scheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func statusDescription(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return http.StatusText(status)
+}
@@ -0,0 +1,63 @@
+package treemuxotel
+
+import "strings"
+
+const defaultParamValueLimit = 256
+
+// defaultSensitiveParams lists the route param names dropped by default,
+// since they routinely carry secrets (tokens, passwords) or PII.
+var defaultSensitiveParams = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"api_key":       true,
+	"apikey":        true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+}
+
+func defaultParamFilter(name, value string) (string, bool) {
+	if defaultSensitiveParams[strings.ToLower(name)] {
+		return "", false
+	}
+	return value, true
+}
+
+// WithParamFilter overrides which route params are attached as span
+// attributes. fn is called with each param's name and value; returning
+// false drops the param entirely, otherwise the returned string is used as
+// its (possibly rewritten) value. Defaults to dropping params commonly used
+// for tokens, passwords and secrets.
+func WithParamFilter(fn func(name, value string) (string, bool)) Option {
+	return func(c *config) {
+		c.paramFilter = fn
+	}
+}
+
+// WithParamRedactor rewrites the value of every route param that survives
+// the filter, e.g. to hash or mask it before it leaves the process.
+func WithParamRedactor(fn func(name, value string) string) Option {
+	return func(c *config) {
+		c.paramRedactor = fn
+	}
+}
+
+// WithParamValueLimit caps the number of bytes of a param value attached to
+// the span, truncating anything longer. Defaults to 256; a limit <= 0
+// disables truncation. This mainly guards against `*` catch-all params
+// capturing arbitrarily long paths.
+func WithParamValueLimit(n int) Option {
+	return func(c *config) {
+		c.paramValueLimit = n
+	}
+}
+
+// WithAllParams disables the default param filter and value truncation,
+// restoring the historical behavior of attaching every route param
+// verbatim.
+func WithAllParams() Option {
+	return func(c *config) {
+		c.paramFilter = nil
+		c.paramValueLimit = 0
+	}
+}
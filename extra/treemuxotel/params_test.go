@@ -0,0 +1,25 @@
+package treemuxotel
+
+import "testing"
+
+func TestTruncateUTF8DoesNotSplitRunes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "ascii well under limit", in: "hello", n: 10, want: "hello"},
+		{name: "ascii cut exactly on boundary", in: "hello", n: 3, want: "hel"},
+		{name: "multi-byte rune would be split, backs off to rune start", in: "日本語", n: 4, want: "日"},
+		{name: "multi-byte rune cut lands exactly on a rune boundary", in: "日本語", n: 6, want: "日本"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateUTF8(tt.in, tt.n); got != tt.want {
+				t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+		})
+	}
+}
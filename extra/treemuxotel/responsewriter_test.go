@@ -0,0 +1,86 @@
+package treemuxotel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriterDefaultsStatusToOK(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	if _, err := rw.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if rw.status != http.StatusOK {
+		t.Errorf("status = %d, want %d", rw.status, http.StatusOK)
+	}
+	if rw.written != 5 {
+		t.Errorf("written = %d, want 5", rw.written)
+	}
+}
+
+func TestResponseWriterCapturesExplicitStatus(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	rw.WriteHeader(http.StatusNotFound)
+	n, err := rw.Write([]byte("not found"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if rw.status != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rw.status, http.StatusNotFound)
+	}
+	if int64(n) != rw.written {
+		t.Errorf("written = %d, want %d", rw.written, n)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("underlying recorder code = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestResponseWriterWriteHeaderIsIdempotent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.WriteHeader(http.StatusInternalServerError)
+
+	if rw.status != http.StatusCreated {
+		t.Errorf("status = %d, want first WriteHeader call to win (%d)", rw.status, http.StatusCreated)
+	}
+}
+
+func TestResponseWriterAccumulatesBytesAcrossWrites(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	rw.Write([]byte("foo"))
+	rw.Write([]byte("bar!"))
+
+	if rw.written != 7 {
+		t.Errorf("written = %d, want 7", rw.written)
+	}
+}
+
+func TestResponseWriterHijackUnsupported(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	if _, _, err := rw.Hijack(); err != http.ErrNotSupported {
+		t.Errorf("Hijack() error = %v, want %v", err, http.ErrNotSupported)
+	}
+}
+
+func TestResponseWriterPushUnsupported(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	if err := rw.Push("/style.css", nil); err != http.ErrNotSupported {
+		t.Errorf("Push() error = %v, want %v", err, http.ErrNotSupported)
+	}
+}
@@ -3,68 +3,199 @@ package treemuxotel
 import (
 	"net"
 	"net/http"
+	"unicode/utf8"
 
 	"github.com/vmihailenco/treemux"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/propagation"
 	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/label"
 	"go.opentelemetry.io/otel/semconv"
 )
 
+const instrumentationName = "github.com/vmihailenco/treemux/extra/treemuxotel"
+
 type config struct {
 	clientIP bool
+
+	trustedProxies  []*net.IPNet
+	clientIPHeaders []string
+	remoteIPFunc    func(*http.Request) string
+
+	tracerProvider trace.Provider
+	propagators    propagation.Propagators
+
+	spanNameFormatter func(route string, req *treemux.Request) string
+	publicEndpointFn  func(*http.Request) bool
+
+	filter func(req treemux.Request) bool
+
+	paramFilter     func(name, value string) (string, bool)
+	paramRedactor   func(name, value string) string
+	paramValueLimit int
 }
 
+// Option configures the treemuxotel middleware.
 type Option func(c *config)
 
+// WithClientIP toggles whether the client IP is attached to the span as
+// http.client_ip. It is on by default.
 func WithClientIP(on bool) Option {
 	return func(c *config) {
 		c.clientIP = on
 	}
 }
 
-func NewMiddleware(opts ...Option) treemux.MiddlewareFunc {
+// WithTracerProvider sets the trace.Provider used to create spans. It
+// defaults to the global provider.
+func WithTracerProvider(provider trace.Provider) Option {
+	return func(c *config) {
+		if provider != nil {
+			c.tracerProvider = provider
+		}
+	}
+}
+
+// WithPropagators sets the propagators used to extract span context from
+// incoming request headers. It defaults to the global propagators.
+func WithPropagators(propagators propagation.Propagators) Option {
+	return func(c *config) {
+		if propagators != nil {
+			c.propagators = propagators
+		}
+	}
+}
+
+// WithSpanNameFormatter overrides how the server span name is derived from
+// the matched treemux route. It defaults to the route pattern itself, since
+// using the raw request URL blows up span-name cardinality.
+func WithSpanNameFormatter(fn func(route string, req *treemux.Request) string) Option {
+	return func(c *config) {
+		c.spanNameFormatter = fn
+	}
+}
+
+// WithPublicEndpoint marks the handler as a public-facing endpoint: the
+// span context extracted from incoming headers is linked to the new server
+// span instead of being treated as its parent.
+func WithPublicEndpoint() Option {
+	return func(c *config) {
+		c.publicEndpointFn = func(*http.Request) bool { return true }
+	}
+}
+
+// WithPublicEndpointFn is like WithPublicEndpoint, but the decision is made
+// per request.
+func WithPublicEndpointFn(fn func(*http.Request) bool) Option {
+	return func(c *config) {
+		c.publicEndpointFn = fn
+	}
+}
+
+// WithFilter skips instrumentation for requests for which fn returns false.
+// It is commonly used to exclude health-check or metrics endpoints.
+//
+// There is intentionally no per-route sampler option: in this version of
+// the OTel API the sampling decision is made by the TracerProvider from
+// the trace ID and parent context, not by an option on an individual span,
+// so a route can't be sampled differently without starting its spans on a
+// separate TracerProvider - one with its own exporters and processors
+// wired up, which this middleware has no way to do on the caller's behalf
+// without silently dropping their spans. Route-aware sampling needs a
+// Sampler that inspects the route (e.g. from span attributes) installed on
+// the TracerProvider passed to WithTracerProvider.
+func WithFilter(fn func(req treemux.Request) bool) Option {
+	return func(c *config) {
+		c.filter = fn
+	}
+}
+
+func newConfig(opts []Option) *config {
 	c := &config{
-		clientIP: true,
+		clientIP:        true,
+		clientIPHeaders: []string{"X-Forwarded-For"},
+		tracerProvider:  global.TraceProvider(),
+		propagators:     global.Propagators(),
+		paramFilter:     defaultParamFilter,
+		paramValueLimit: defaultParamValueLimit,
 	}
 	for _, opt := range opts {
 		opt(c)
 	}
-	return c.Middleware
+	return c
+}
+
+// NewMiddleware returns a treemux.MiddlewareFunc that annotates the span
+// already present in the request context (started, for example, by
+// Handler/Middleware, or by an outer otelhttp.NewHandler) with route,
+// client IP and param attributes. Unlike Handler, it does not start a span
+// of its own.
+func NewMiddleware(opts ...Option) treemux.MiddlewareFunc {
+	c := newConfig(opts)
+	return c.annotate
 }
 
-func (c *config) Middleware(next treemux.HandlerFunc) treemux.HandlerFunc {
+func (c *config) annotate(next treemux.HandlerFunc) treemux.HandlerFunc {
 	return func(w http.ResponseWriter, req treemux.Request) error {
 		span := trace.SpanFromContext(req.Context())
 		if !span.IsRecording() {
 			return next(w, req)
 		}
 
-		attrs := make([]label.KeyValue, 0, 2+len(req.Params))
-		attrs = append(attrs, semconv.HTTPRouteKey.String(req.Route()))
+		span.SetAttributes(semconv.HTTPRouteKey.String(req.Route()))
 		if c.clientIP {
-			attrs = append(attrs, semconv.HTTPClientIPKey.String(remoteAddr(req.Request)))
+			span.SetAttributes(semconv.HTTPClientIPKey.String(c.remoteAddr(req.Request)))
 		}
+		span.SetAttributes(c.paramAttributes(req)...)
 
-		for _, param := range req.Params {
-			name := param.Name
-			if name == "" {
-				name = "*"
-			}
+		return next(w, req)
+	}
+}
 
-			attrs = append(attrs, label.String("http.route.param."+name, param.Value))
+func (c *config) paramAttributes(req treemux.Request) []label.KeyValue {
+	attrs := make([]label.KeyValue, 0, len(req.Params))
+	for _, param := range req.Params {
+		name := param.Name
+		if name == "" {
+			name = "*"
+		}
+
+		value := param.Value
+		if c.paramFilter != nil {
+			var keep bool
+			value, keep = c.paramFilter(name, value)
+			if !keep {
+				continue
+			}
+		}
+		if c.paramRedactor != nil {
+			value = c.paramRedactor(name, value)
+		}
+		if c.paramValueLimit > 0 && len(value) > c.paramValueLimit {
+			value = truncateUTF8(value, c.paramValueLimit)
 		}
 
-		span.SetAttributes(attrs...)
+		attrs = append(attrs, label.String("http.route.param."+name, value))
+	}
+	return attrs
+}
 
-		return next(w, req)
+// truncateUTF8 cuts s to at most n bytes without splitting a multi-byte
+// rune in half.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
 	}
+	return s[:n]
 }
 
-func remoteAddr(req *http.Request) string {
-	forwarded := req.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		return forwarded
+func spanStatusFromHTTPStatus(status int) codes.Code {
+	if status >= http.StatusInternalServerError {
+		return codes.Error
 	}
-	host, _, _ := net.SplitHostPort(req.RemoteAddr)
-	return host
+	return codes.Unset
 }